@@ -1,17 +1,16 @@
 package memory
 
 import (
+	"bufio"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/bits"
-	"slices"
-	"sort"
+	"sync/atomic"
 
 	"github.com/ethereum-optimism/optimism/cannon/mipsevm/arch"
 	"github.com/ethereum/go-ethereum/crypto"
-	"golang.org/x/exp/maps"
 )
 
 // Note: 2**12 = 4 KiB, the min phys page size in the Go runtime.
@@ -27,6 +26,31 @@ const (
 	MemProofSize      = arch.MemProofSize
 )
 
+// topBits/bottomBits split a PageKeySize-bit page index into two radix
+// levels of about PageKeySize/2 bits each (1024-way fanout for a 20-bit
+// page key), loosely modeled on the Go
+// runtime's two-level page allocator summary: a fixed-fanout array of
+// buckets at the top, each owning a fixed-fanout array of pages at the
+// bottom, rather than a flat map per page.
+const (
+	topBits      = PageKeySize / 2
+	bottomBits   = PageKeySize - topBits
+	topFanout    = 1 << topBits
+	bottomFanout = 1 << bottomBits
+)
+
+// Sparse page encoding used by Serialize/Deserialize and the JSON codec:
+// most cannon program memory is mostly zero (stack + a handful of code/data
+// pages with large zero holes in between), so each page is written as a
+// bitmap marking which pageBitmapBlockSize-byte blocks are non-zero,
+// followed by only those blocks' bytes, rather than its full PageSize.
+const (
+	serializeMagic       = "csr1" // cannon sparse (block-bitmap) page format, v1
+	pageBitmapBlockSize  = 64
+	pageBitmapBlockCount = PageSize / pageBitmapBlockSize
+	pageBitmapSize       = pageBitmapBlockCount / 8
+)
+
 type Word = arch.Word
 
 func HashPair(left, right [32]byte) [32]byte {
@@ -44,12 +68,58 @@ var zeroHashes = func() [256][32]byte {
 	return out
 }()
 
-type Memory struct {
-	// generalized index -> merkle root or nil if invalidated
-	nodes map[uint64]*[32]byte
+// pageSlot holds one page within a pageBucket, tagged with the generation
+// of the Memory that privately owns it. A page may still be referenced by
+// another forked Memory as long as its gen is stale (see Memory.Fork).
+type pageSlot struct {
+	page *CachedPage
+	gen  uint64
+}
+
+// pageBucket is the bottom level of the radix index: it owns bottomFanout
+// actual pages, plus the cached interior merkle hashes covering the depth
+// range directly above them (down to, but not including, the page roots
+// themselves - those are cached inside each CachedPage).
+type pageBucket struct {
+	pages [bottomFanout]pageSlot
+	// local[gindex] caches the merkle hash of generalized index gindex,
+	// relative to this bucket's own root (gindex 1). A nil entry means the
+	// hash has been invalidated (or never computed) and must be recomputed.
+	local [bottomFanout]*[32]byte
+	// count is the number of allocated pages in this bucket. bucketRootHash
+	// and merkleizeBucketLevel consult it to short-circuit a bucket that is
+	// allocated but still empty, the same way a nil bucket short-circuits.
+	count int
+	// gen is the generation of the Memory that privately owns this bucket
+	// struct (its pages/local arrays). A stale gen means some other forked
+	// Memory may still reference the very same *pageBucket.
+	gen uint64
+}
 
-	// pageIndex -> cached page
-	pages map[Word]*CachedPage
+type Memory struct {
+	// buckets is the top level of the radix index: each entry routes
+	// topBits of a page index to a pageBucket, or stays nil while that
+	// entire range has no allocated pages. A nil bucket lets
+	// MerkleizeSubtree short-circuit an entirely-zero subtree in O(1)
+	// instead of recursing into zeroHashes.
+	buckets [topFanout]*pageBucket
+
+	// topLocal[gindex] caches the merkle hash of generalized index gindex,
+	// relative to the true root, for the top level's own depth range
+	// [1, topBits]. A nil entry means the hash needs (re)computing.
+	// invalidateSummary clears these bottom-up and stops as soon as it
+	// finds an entry that is already nil, since everything above it must
+	// already be nil too.
+	topLocal [topFanout]*[32]byte
+
+	// count is the total number of allocated pages across all buckets.
+	count int
+
+	// gen identifies this Memory for copy-on-write purposes: a bucket or
+	// page whose own gen doesn't match m.gen may still be shared with
+	// another Memory from a Fork, and must be cloned before it is mutated.
+	// See Fork, ownedBucket and ownedPage.
+	gen uint64
 
 	// Note: since we don't de-alloc pages, we don't do ref-counting.
 	// Once a page exists, it doesn't leave memory
@@ -62,20 +132,110 @@ type Memory struct {
 
 func NewMemory() *Memory {
 	return &Memory{
-		nodes:        make(map[uint64]*[32]byte),
-		pages:        make(map[Word]*CachedPage),
 		lastPageKeys: [2]Word{^Word(0), ^Word(0)}, // default to invalid keys, to not match any pages
 	}
 }
 
+// memGen hands out the generation numbers used for copy-on-write sharing;
+// see Memory.Fork. It's an atomic counter, not a plain uint64, because
+// fuzzers and step-replay tools are expected to call Fork concurrently
+// across goroutines; a racy increment could hand out the same generation
+// to two unrelated Memorys and let them treat each other's buckets/pages as
+// privately owned, corrupting both on the next write.
+var memGen atomic.Uint64
+
+// Fork returns a copy-on-write child of m. Unlike the old Copy, Fork does
+// not clone any bucket or page up front - it shares all of them with m
+// until either Memory writes to one, at which point only the bucket (and,
+// within it, only the specific page) actually being written is cloned via
+// ownedBucket/ownedPage. This makes Fork, and any read-only use of the
+// result, cost O(1) regardless of how much memory is allocated: cloning
+// only happens lazily, and only for pages that are later actually touched.
+func (m *Memory) Fork() *Memory {
+	child := *m
+	// Both m and child must clone on their next write to anything that
+	// existed before the fork - otherwise one of them could mutate a
+	// bucket/page the other still thinks it owns exclusively.
+	m.gen = memGen.Add(1)
+	child.gen = memGen.Add(1)
+	return &child
+}
+
+// ownedBucket returns the bucket responsible for topIdx, creating or
+// cloning it first if it doesn't already belong to m's generation. Cloning
+// is shallow: it copies the bucket's own pages/local arrays (cheap, fixed
+// size), not the page contents or hashes they point to, so individual
+// pages remain copy-on-write at their own granularity (see ownedPage).
+func (m *Memory) ownedBucket(topIdx Word) *pageBucket {
+	b := m.buckets[topIdx]
+	if b == nil {
+		b = &pageBucket{gen: m.gen}
+		m.buckets[topIdx] = b
+		return b
+	}
+	if b.gen == m.gen {
+		return b
+	}
+	nb := new(pageBucket)
+	*nb = *b
+	nb.gen = m.gen
+	m.buckets[topIdx] = nb
+	return nb
+}
+
+// ownedPage returns the page at pageIndex for writing, cloning its bucket
+// and/or the page itself first if either might still be shared with
+// another Memory from a Fork. The caller must already know the page
+// exists (e.g. via a prior pageLookup).
+func (m *Memory) ownedPage(pageIndex Word) *CachedPage {
+	topIdx := pageIndex >> bottomBits
+	bottomIdx := pageIndex & (bottomFanout - 1)
+	b := m.ownedBucket(topIdx)
+
+	slot := b.pages[bottomIdx]
+	p := slot.page
+	if slot.gen != m.gen {
+		p = clonePage(slot.page)
+		b.pages[bottomIdx] = pageSlot{page: p, gen: m.gen}
+	}
+
+	// the fast-path page cache may still hold the pre-clone pointer
+	if m.lastPageKeys[0] == pageIndex {
+		m.lastPage[0] = p
+	}
+	if m.lastPageKeys[1] == pageIndex {
+		m.lastPage[1] = p
+	}
+	return p
+}
+
+// clonePage returns a fresh CachedPage holding a private copy of p's data.
+// Its own merkle cache starts out empty, exactly as it would for a newly
+// allocated page: nothing has this particular *CachedPage cached anywhere
+// else yet, so there is nothing to carry over.
+func clonePage(p *CachedPage) *CachedPage {
+	data := new(Page)
+	*data = *p.Data
+	return &CachedPage{Data: data}
+}
+
 func (m *Memory) PageCount() int {
-	return len(m.pages)
+	return m.count
 }
 
 func (m *Memory) ForEachPage(fn func(pageIndex Word, page *Page) error) error {
-	for pageIndex, cachedPage := range m.pages {
-		if err := fn(pageIndex, cachedPage.Data); err != nil {
-			return err
+	for topIdx, b := range m.buckets {
+		if b == nil {
+			continue
+		}
+		for bottomIdx, slot := range b.pages {
+			if slot.page == nil {
+				continue
+			}
+			pageIndex := (Word(topIdx) << bottomBits) | Word(bottomIdx)
+			if err := fn(pageIndex, slot.page.Data); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -89,28 +249,95 @@ func (m *Memory) MerkleizeSubtree(gindex uint64) [32]byte {
 	if l > PageKeySize {
 		depthIntoPage := l - 1 - PageKeySize
 		pageIndex := (gindex >> depthIntoPage) & PageKeyMask
-		if p, ok := m.pages[Word(pageIndex)]; ok {
+		if p, ok := m.pageLookup(Word(pageIndex)); ok {
 			pageGindex := (1 << depthIntoPage) | (gindex & ((1 << depthIntoPage) - 1))
 			return p.MerkleizeSubtree(pageGindex)
 		} else {
 			return zeroHashes[MemProofLeafCount-l] // page does not exist
 		}
 	}
-	n, ok := m.nodes[gindex]
-	if !ok {
-		// if the node doesn't exist, the whole sub-tree is zeroed
+	if l > topBits {
+		return m.merkleizeBucketLevel(gindex, l)
+	}
+	return m.merkleizeTop(gindex, l)
+}
+
+// merkleizeBucketLevel computes the merkle hash of gindex when l falls in
+// the bucket level's own depth range (topBits, PageKeySize], the same way
+// MerkleizeSubtree's page-level branch does: split gindex into the topBits
+// selecting the owning bucket and the remaining depth within it, then
+// short-circuit in O(1) via zeroHashes if that bucket was never allocated.
+func (m *Memory) merkleizeBucketLevel(gindex, l uint64) [32]byte {
+	depthIntoBucket := l - 1 - topBits
+	topIdx := (gindex >> depthIntoBucket) & (topFanout - 1)
+	b := m.buckets[topIdx]
+	if b == nil || b.count == 0 {
 		return zeroHashes[MemProofLeafCount-l]
 	}
-	if n != nil {
-		return *n
+	bucketGindex := (uint64(1) << depthIntoBucket) | (gindex & ((uint64(1) << depthIntoBucket) - 1))
+	return m.merkleizeBucket(b, bucketGindex, l)
+}
+
+// merkleizeTop computes the merkle hash of gindex within the top radix
+// level (l <= topBits), consulting/populating topLocal, and crosses into
+// the bucket level via bucketRootHash once it reaches a leaf.
+func (m *Memory) merkleizeTop(gindex, l uint64) [32]byte {
+	if cached := m.topLocal[gindex]; cached != nil {
+		return *cached
+	}
+	var left, right [32]byte
+	if l == topBits {
+		left = m.bucketRootHash(int((gindex<<1)-uint64(topFanout)), l+1)
+		right = m.bucketRootHash(int(((gindex<<1)|1)-uint64(topFanout)), l+1)
+	} else {
+		left = m.merkleizeTop(gindex<<1, l+1)
+		right = m.merkleizeTop((gindex<<1)|1, l+1)
 	}
-	left := m.MerkleizeSubtree(gindex << 1)
-	right := m.MerkleizeSubtree((gindex << 1) | 1)
 	r := HashPair(left, right)
-	m.nodes[gindex] = &r
+	m.topLocal[gindex] = &r
 	return r
 }
 
+// bucketRootHash returns the merkle root of the bucket at the given top
+// index, or a precomputed zero hash in O(1) if the bucket was never
+// allocated, or was allocated but has since been left with no pages in it
+// (i.e. its whole subtree is empty either way).
+func (m *Memory) bucketRootHash(topIdx int, l uint64) [32]byte {
+	b := m.buckets[topIdx]
+	if b == nil || b.count == 0 {
+		return zeroHashes[MemProofLeafCount-l]
+	}
+	return m.merkleizeBucket(b, 1, l)
+}
+
+// merkleizeBucket computes the merkle hash of gindex, relative to the
+// bucket's own root, consulting/populating b.local, and crosses into the
+// actual page contents via pageRootHash once it reaches a leaf.
+func (m *Memory) merkleizeBucket(b *pageBucket, gindex, l uint64) [32]byte {
+	if cached := b.local[gindex]; cached != nil {
+		return *cached
+	}
+	var left, right [32]byte
+	if l == PageKeySize {
+		left = m.pageRootHash(b, int((gindex<<1)-uint64(bottomFanout)), l+1)
+		right = m.pageRootHash(b, int(((gindex<<1)|1)-uint64(bottomFanout)), l+1)
+	} else {
+		left = m.merkleizeBucket(b, gindex<<1, l+1)
+		right = m.merkleizeBucket(b, (gindex<<1)|1, l+1)
+	}
+	r := HashPair(left, right)
+	b.local[gindex] = &r
+	return r
+}
+
+func (m *Memory) pageRootHash(b *pageBucket, bottomIdx int, l uint64) [32]byte {
+	p := b.pages[bottomIdx].page
+	if p == nil {
+		return zeroHashes[MemProofLeafCount-l]
+	}
+	return p.MerkleizeSubtree(1)
+}
+
 func (m *Memory) MerkleProof(addr Word) (out [MemProofSize]byte) {
 	proof := m.traverseBranch(1, addr, 0)
 	// encode the proof
@@ -152,7 +379,13 @@ func (m *Memory) pageLookup(pageIndex Word) (*CachedPage, bool) {
 	if pageIndex == m.lastPageKeys[1] {
 		return m.lastPage[1], true
 	}
-	p, ok := m.pages[pageIndex]
+	b := m.buckets[pageIndex>>bottomBits]
+	var p *CachedPage
+	ok := false
+	if b != nil {
+		p = b.pages[pageIndex&(bottomFanout-1)].page
+		ok = p != nil
+	}
 
 	// only cache existing pages.
 	if ok {
@@ -180,22 +413,48 @@ func (m *Memory) SetWord(addr Word, v Word) {
 		// Go may mmap relatively large ranges, but we only allocate the pages just in time.
 		p = m.AllocPage(pageIndex)
 	} else {
+		// read the pre-clone cache state first: ownedPage may hand back a
+		// freshly cloned page with its cache reset, which would otherwise
+		// look indistinguishable from a page that was already invalid.
 		prevValid := p.Ok[1]
+		// the page may still be shared with another Memory from a Fork;
+		// own a private copy before mutating it.
+		p = m.ownedPage(pageIndex)
 		p.invalidate(pageAddr)
 		if prevValid { // if the page was already invalid before, then nodes to mem-root will also still be.
+			m.invalidateSummary(pageIndex)
+		}
+	}
+	arch.ByteOrderWord.PutWord(p.Data[pageAddr:pageAddr+arch.WordSizeBytes], v)
+}
 
-			// find the gindex of the first page covering the address: i.e. ((1 << WordSize) | addr) >> PageAddrSize
-			// Avoid 64-bit overflow by distributing the right shift across the OR.
-			gindex := (uint64(1) << (WordSize - PageAddrSize)) | uint64(addr>>PageAddrSize)
-
-			for gindex > 0 {
-				m.nodes[gindex] = nil
-				gindex >>= 1
+// invalidateSummary clears the cached hashes on the path from pageIndex up
+// to the root of the radix index, stopping as soon as it reaches an entry
+// that is already nil - every cached hash above that point must already be
+// nil too, so there is nothing left to clear.
+func (m *Memory) invalidateSummary(pageIndex Word) {
+	topIdx := pageIndex >> bottomBits
+	bottomIdx := pageIndex & (bottomFanout - 1)
+
+	if b := m.buckets[topIdx]; b != nil {
+		gindex := (uint64(bottomFanout) | uint64(bottomIdx)) >> 1
+		for gindex > 0 {
+			if b.local[gindex] == nil {
+				break
 			}
+			b.local[gindex] = nil
+			gindex >>= 1
+		}
+	}
 
+	gindex := (uint64(topFanout) | uint64(topIdx)) >> 1
+	for gindex > 0 {
+		if m.topLocal[gindex] == nil {
+			break
 		}
+		m.topLocal[gindex] = nil
+		gindex >>= 1
 	}
-	arch.ByteOrderWord.PutWord(p.Data[pageAddr:pageAddr+arch.WordSizeBytes], v)
 }
 
 // GetWord reads the maximum sized value, [arch.Word], located at the specified address.
@@ -214,32 +473,94 @@ func (m *Memory) GetWord(addr Word) Word {
 }
 
 func (m *Memory) AllocPage(pageIndex Word) *CachedPage {
+	topIdx := pageIndex >> bottomBits
+	bottomIdx := pageIndex & (bottomFanout - 1)
+
+	b := m.ownedBucket(topIdx)
 	p := &CachedPage{Data: new(Page)}
-	m.pages[pageIndex] = p
-	// make nodes to root
-	k := (1 << PageKeySize) | uint64(pageIndex)
-	for k > 0 {
-		m.nodes[k] = nil
-		k >>= 1
-	}
+	b.pages[bottomIdx] = pageSlot{page: p, gen: m.gen}
+	b.count++
+	m.count++
+	// make nodes to root stale
+	m.invalidateSummary(pageIndex)
 	return p
 }
 
+// sparsifyPage splits page into its non-zero pageBitmapBlockSize-byte
+// blocks, returning a bitmap marking which blocks are non-zero and the
+// blocks themselves in bitmap order.
+func sparsifyPage(page *Page) (bitmap [pageBitmapSize]byte, blocks [][]byte) {
+	for i := 0; i < pageBitmapBlockCount; i++ {
+		block := page[i*pageBitmapBlockSize : (i+1)*pageBitmapBlockSize]
+		if isZeroBlock(block) {
+			continue
+		}
+		bitmap[i/8] |= 1 << (i % 8)
+		blocks = append(blocks, block)
+	}
+	return bitmap, blocks
+}
+
+func isZeroBlock(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// desparsifyPage is the inverse of sparsifyPage. It returns a nil page if
+// the bitmap marks the page as fully zero, so the caller can leave it
+// unmaterialized rather than allocating a page of zeroes. It returns an
+// error, rather than panicking, if blocks does not have exactly one entry
+// per bit set in bitmap - this decodes externally-sourced state (e.g.
+// dispute-game inputs), so a malformed or truncated Blocks array must fail
+// gracefully like the dense and binary sparse decode paths already do.
+func desparsifyPage(bitmap [pageBitmapSize]byte, blocks [][]byte) (*Page, error) {
+	if bitmap == ([pageBitmapSize]byte{}) {
+		return nil, nil
+	}
+	want := 0
+	for _, bm := range bitmap {
+		want += bits.OnesCount8(bm)
+	}
+	if len(blocks) != want {
+		return nil, fmt.Errorf("invalid sparse page: bitmap marks %d blocks but got %d", want, len(blocks))
+	}
+	page := new(Page)
+	next := 0
+	for i := 0; i < pageBitmapBlockCount; i++ {
+		if bitmap[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		copy(page[i*pageBitmapBlockSize:(i+1)*pageBitmapBlockSize], blocks[next])
+		next++
+	}
+	return page, nil
+}
+
 type pageEntry struct {
-	Index Word  `json:"index"`
-	Data  *Page `json:"data"`
+	Index Word `json:"index"`
+
+	// Data holds the page as a full dense blob, for backward compatibility
+	// with memory dumps written before the sparse format below existed.
+	Data *Page `json:"data,omitempty"`
+
+	// Bitmap and Blocks are the sparse encoding: Bitmap marks which
+	// pageBitmapBlockSize-byte blocks of the page are non-zero, and Blocks
+	// holds just those blocks, in bitmap order. A page that is fully zero
+	// is written with an all-zero Bitmap and no Blocks.
+	Bitmap []byte   `json:"bitmap,omitempty"`
+	Blocks [][]byte `json:"blocks,omitempty"`
 }
 
 func (m *Memory) MarshalJSON() ([]byte, error) { // nosemgrep
-	pages := make([]pageEntry, 0, len(m.pages))
-	for k, p := range m.pages {
-		pages = append(pages, pageEntry{
-			Index: k,
-			Data:  p.Data,
-		})
-	}
-	sort.Slice(pages, func(i, j int) bool {
-		return pages[i].Index < pages[j].Index
+	pages := make([]pageEntry, 0, m.count)
+	_ = m.ForEachPage(func(pageIndex Word, page *Page) error {
+		bitmap, blocks := sparsifyPage(page)
+		pages = append(pages, pageEntry{Index: pageIndex, Bitmap: bitmap[:], Blocks: blocks})
+		return nil
 	})
 	return json.Marshal(pages)
 }
@@ -249,15 +570,25 @@ func (m *Memory) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &pages); err != nil {
 		return err
 	}
-	m.nodes = make(map[uint64]*[32]byte)
-	m.pages = make(map[Word]*CachedPage)
-	m.lastPageKeys = [2]Word{^Word(0), ^Word(0)}
-	m.lastPage = [2]*CachedPage{nil, nil}
+	*m = *NewMemory()
 	for i, p := range pages {
-		if _, ok := m.pages[p.Index]; ok {
+		if _, ok := m.pageLookup(p.Index); ok {
 			return fmt.Errorf("cannot load duplicate page, entry %d, page index %d", i, p.Index)
 		}
-		m.AllocPage(p.Index).Data = p.Data
+		if p.Data != nil {
+			m.AllocPage(p.Index).Data = p.Data
+			continue
+		}
+		var bitmap [pageBitmapSize]byte
+		copy(bitmap[:], p.Bitmap)
+		page, err := desparsifyPage(bitmap, p.Blocks)
+		if err != nil {
+			return fmt.Errorf("entry %d, page index %d: %w", i, p.Index, err)
+		}
+		if page != nil {
+			m.AllocPage(p.Index).Data = page
+		}
+		// else: fully zero page, leave unmaterialized until first write.
 	}
 	return nil
 }
@@ -279,6 +610,10 @@ func (m *Memory) SetMemoryRange(addr Word, r io.Reader) error {
 		p, ok := m.pageLookup(pageIndex)
 		if !ok {
 			p = m.AllocPage(pageIndex)
+		} else {
+			// the page may still be shared with another Memory from a Fork;
+			// own a private copy before mutating it.
+			p = m.ownedPage(pageIndex)
 		}
 		p.InvalidateFull()
 		copy(p.Data[pageAddr:], chunk[:n])
@@ -286,35 +621,89 @@ func (m *Memory) SetMemoryRange(addr Word, r io.Reader) error {
 	}
 }
 
-// Serialize writes the memory in a simple binary format which can be read again using Deserialize
-// The format is a simple concatenation of fields, with prefixed item count for repeating items and using big endian
-// encoding for numbers.
+// Serialize writes the memory in a sparse, block-bitmap-encoded binary
+// format which can be read again using Deserialize. The format is
+// self-describing via a magic prefix, with prefixed item counts for
+// repeating items and big endian encoding for numbers.
 //
-// len(PageCount)    Word
+// magic                 [4]byte ("csr1")
+// len(PageCount)         Word
 // For each page (order is arbitrary):
 //
 //	page index          Word
-//	page Data           [PageSize]byte
+//	bitmap              [pageBitmapSize]byte, bit i set iff block i is non-zero
+//	for each set bit i, in order: block bytes   [pageBitmapBlockSize]byte
 func (m *Memory) Serialize(out io.Writer) error {
+	if _, err := out.Write([]byte(serializeMagic)); err != nil {
+		return err
+	}
 	if err := binary.Write(out, binary.BigEndian, Word(m.PageCount())); err != nil {
 		return err
 	}
-	indexes := maps.Keys(m.pages)
-	// iterate sorted map keys for consistent serialization
-	slices.Sort(indexes)
-	for _, pageIndex := range indexes {
-		page := m.pages[pageIndex]
+	return m.ForEachPage(func(pageIndex Word, page *Page) error {
 		if err := binary.Write(out, binary.BigEndian, pageIndex); err != nil {
 			return err
 		}
-		if _, err := out.Write(page.Data[:]); err != nil {
+		bitmap, blocks := sparsifyPage(page)
+		if _, err := out.Write(bitmap[:]); err != nil {
 			return err
 		}
+		for _, block := range blocks {
+			if _, err := out.Write(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Deserialize reads memory written by Serialize. It also accepts the old
+// dense format (no magic prefix, full PageSize bytes per page) so blobs
+// written before the sparse format existed still load correctly.
+func (m *Memory) Deserialize(in io.Reader) error {
+	br := bufio.NewReader(in)
+	if head, err := br.Peek(len(serializeMagic)); err == nil && string(head) == serializeMagic {
+		if _, err := br.Discard(len(serializeMagic)); err != nil {
+			return err
+		}
+		return m.deserializeSparse(br)
+	}
+	return m.deserializeDense(br)
+}
+
+func (m *Memory) deserializeSparse(in io.Reader) error {
+	var pageCount Word
+	if err := binary.Read(in, binary.BigEndian, &pageCount); err != nil {
+		return err
+	}
+	for i := Word(0); i < pageCount; i++ {
+		var pageIndex Word
+		if err := binary.Read(in, binary.BigEndian, &pageIndex); err != nil {
+			return err
+		}
+		var bitmap [pageBitmapSize]byte
+		if _, err := io.ReadFull(in, bitmap[:]); err != nil {
+			return err
+		}
+		if bitmap == ([pageBitmapSize]byte{}) {
+			// fully zero page: leave unmaterialized until first write.
+			continue
+		}
+		page := m.AllocPage(pageIndex).Data
+		for j := 0; j < pageBitmapBlockCount; j++ {
+			if bitmap[j/8]&(1<<(j%8)) == 0 {
+				continue
+			}
+			block := page[j*pageBitmapBlockSize : (j+1)*pageBitmapBlockSize]
+			if _, err := io.ReadFull(in, block); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func (m *Memory) Deserialize(in io.Reader) error {
+func (m *Memory) deserializeDense(in io.Reader) error {
 	var pageCount Word
 	if err := binary.Read(in, binary.BigEndian, &pageCount); err != nil {
 		return err
@@ -332,18 +721,10 @@ func (m *Memory) Deserialize(in io.Reader) error {
 	return nil
 }
 
+// Copy returns a copy-on-write child of m; see Fork. Kept as its own method
+// since it's the long-standing entry point callers already use.
 func (m *Memory) Copy() *Memory {
-	out := NewMemory()
-	out.nodes = make(map[uint64]*[32]byte)
-	out.pages = make(map[Word]*CachedPage)
-	out.lastPageKeys = [2]Word{^Word(0), ^Word(0)}
-	out.lastPage = [2]*CachedPage{nil, nil}
-	for k, page := range m.pages {
-		data := new(Page)
-		*data = *page.Data
-		out.AllocPage(k).Data = data
-	}
-	return out
+	return m.Fork()
 }
 
 type memReader struct {
@@ -384,7 +765,7 @@ func (m *Memory) ReadMemoryRange(addr Word, count Word) io.Reader {
 }
 
 func (m *Memory) UsageRaw() uint64 {
-	return uint64(len(m.pages)) * PageSize
+	return uint64(m.count) * PageSize
 }
 
 func (m *Memory) Usage() string {
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchWriteSparse writes one word into n pages spaced 16 MiB apart,
+// leaving large zero gaps between them.
+func benchWriteSparse(m *Memory, n int) {
+	const stride = 0x1000 * 4096 // far enough apart to land in different buckets
+	for i := 0; i < n; i++ {
+		m.SetWord(Word(i*stride), Word(i+1))
+	}
+}
+
+// benchWriteDense writes one word into each of n contiguous pages.
+func benchWriteDense(m *Memory, n int) {
+	for i := 0; i < n; i++ {
+		m.SetWord(Word(i*PageSize), Word(i+1))
+	}
+}
+
+// benchWriteScattered writes one word into n pages at pseudo-random (but
+// deterministic) offsets, exercising buckets and pages in no particular
+// order.
+func benchWriteScattered(m *Memory, n int) {
+	var x uint64 = 0x2545F4914F6CDD1D
+	for i := 0; i < n; i++ {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		pageIndex := Word(x) & PageKeyMask
+		m.SetWord(pageIndex<<PageAddrSize, Word(i+1))
+	}
+}
+
+func benchmarkMerkleRoot(b *testing.B, write func(m *Memory, n int), n int) {
+	m := NewMemory()
+	write(m, n)
+	m.MerkleRoot() // warm the cache once, matching typical usage between writes
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.MerkleRoot()
+	}
+}
+
+func BenchmarkMerkleRootSparse(b *testing.B) {
+	benchmarkMerkleRoot(b, benchWriteSparse, 32)
+}
+
+func BenchmarkMerkleRootDense(b *testing.B) {
+	benchmarkMerkleRoot(b, benchWriteDense, 4096)
+}
+
+func BenchmarkMerkleRootScattered(b *testing.B) {
+	benchmarkMerkleRoot(b, benchWriteScattered, 4096)
+}
+
+// BenchmarkMerkleRootAfterWrite measures the bulk-write-then-root pattern
+// the radix summary index targets: writes invalidate only the path to the
+// root, and MerkleRoot recomputes just the stale subtrees.
+func BenchmarkMerkleRootAfterWrite(b *testing.B) {
+	for _, n := range []int{8, 256, 4096} {
+		n := n
+		b.Run(fmt.Sprintf("pages=%d", n), func(b *testing.B) {
+			m := NewMemory()
+			benchWriteDense(m, n)
+			m.MerkleRoot()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.SetWord(Word(i%n)*PageSize, Word(i))
+				m.MerkleRoot()
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestForkThenWriteInvalidatesStaleCache guards against a bug where SetWord
+// read p.Ok[1] after ownedPage had already cloned the page, so a fresh
+// clone's always-false cache flag was mistaken for "already invalid" and
+// invalidateSummary was skipped. The parent's bucket/top hash caches are
+// shared by pointer with the fork until explicitly invalidated, so the
+// child's MerkleRoot kept returning the pre-write root.
+func TestForkThenWriteInvalidatesStaleCache(t *testing.T) {
+	parent := NewMemory()
+	parent.SetWord(0x1000, 0xaaaa)
+	parentRoot := parent.MerkleRoot() // populate every cache along the path
+
+	child := parent.Fork()
+	child.SetWord(0x1000, 0xbbbb)
+
+	childRoot := child.MerkleRoot()
+	if childRoot == parentRoot {
+		t.Fatalf("expected child root to change after write, got stale parent root %x", childRoot)
+	}
+	if parent.MerkleRoot() != parentRoot {
+		t.Fatalf("parent root changed after writing to its fork")
+	}
+}
+
+// TestConcurrentForkGetsDistinctGenerations guards against memGen being a
+// plain (non-atomic) counter: a racy ++ could hand out the same generation
+// to two unrelated Memorys forked concurrently, which would make each treat
+// the other's buckets/pages as privately owned and corrupt both on write.
+// Run with -race to catch the data race directly; this also checks the
+// invariant the race would violate.
+func TestConcurrentForkGetsDistinctGenerations(t *testing.T) {
+	const n = 64
+	// each goroutine forks its own Memory: Fork updates its receiver, so
+	// forking one Memory from several goroutines at once is itself a race.
+	bases := make([]*Memory, n)
+	for i := range bases {
+		bases[i] = NewMemory()
+		bases[i].SetWord(0x1000, 1)
+	}
+
+	gens := make([]uint64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gens[i] = bases[i].Fork().gen
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool, n)
+	for _, g := range gens {
+		if seen[g] {
+			t.Fatalf("duplicate fork generation %d handed out concurrently", g)
+		}
+		seen[g] = true
+	}
+}
+
+// BenchmarkForkThenWrite measures the fork+one-write cost the CoW redesign
+// targets: forking a large memory and then writing a single word should
+// cost O(1) in the number of unmodified pages, not O(total memory) like the
+// old Copy did.
+func BenchmarkForkThenWrite(b *testing.B) {
+	const pages = 4096
+	base := NewMemory()
+	for i := 0; i < pages; i++ {
+		base.SetWord(Word(i*PageSize), Word(i+1))
+	}
+	base.MerkleRoot()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		child := base.Fork()
+		child.SetWord(Word(i%pages)*PageSize, Word(i))
+	}
+}
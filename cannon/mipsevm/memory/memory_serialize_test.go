@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+func newTestMemory() *Memory {
+	m := NewMemory()
+	// a few pages with sparse, non-zero content and large zero holes
+	// between them, plus one page that is entirely zero but still
+	// allocated (e.g. via AllocPage), to exercise both codec paths.
+	m.SetWord(0x1000, 0xdeadbeef)
+	m.SetWord(0x1000+PageSize*3, 0x1)
+	// far enough into the same page to land in a different
+	// pageBitmapBlockSize-byte block than the write above.
+	m.SetWord(0x1000+PageSize*3+pageBitmapBlockSize*4, 0x2)
+	m.AllocPage(7)
+	return m
+}
+
+func TestSerializeRoundTripMatchesDenseRoot(t *testing.T) {
+	m := newTestMemory()
+	want := m.MerkleRoot()
+
+	var buf bytes.Buffer
+	if err := m.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+
+	got := NewMemory()
+	if err := got.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if got.MerkleRoot() != want {
+		t.Fatalf("root mismatch after binary sparse round-trip: got %x, want %x", got.MerkleRoot(), want)
+	}
+	if got.PageCount() != m.PageCount() {
+		t.Fatalf("page count mismatch: got %d, want %d", got.PageCount(), m.PageCount())
+	}
+}
+
+func TestJSONRoundTripMatchesDenseRoot(t *testing.T) {
+	m := newTestMemory()
+	want := m.MerkleRoot()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+
+	got := NewMemory()
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if got.MerkleRoot() != want {
+		t.Fatalf("root mismatch after JSON sparse round-trip: got %x, want %x", got.MerkleRoot(), want)
+	}
+}
+
+func TestDeserializeDenseStillWorks(t *testing.T) {
+	m := newTestMemory()
+	want := m.MerkleRoot()
+
+	var buf bytes.Buffer
+	if err := m.serializeDenseForTest(&buf); err != nil {
+		t.Fatalf("dense serialize: %v", err)
+	}
+
+	got := NewMemory()
+	if err := got.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize (dense): %v", err)
+	}
+	if got.MerkleRoot() != want {
+		t.Fatalf("root mismatch after dense round-trip: got %x, want %x", got.MerkleRoot(), want)
+	}
+}
+
+// serializeDenseForTest writes the old dense format (no magic prefix, full
+// PageSize bytes per page), so Deserialize's backward-compatibility path
+// can be exercised without a separately maintained fixture file.
+func (m *Memory) serializeDenseForTest(out *bytes.Buffer) error {
+	if err := binary.Write(out, binary.BigEndian, Word(m.PageCount())); err != nil {
+		return err
+	}
+	return m.ForEachPage(func(pageIndex Word, page *Page) error {
+		if err := binary.Write(out, binary.BigEndian, pageIndex); err != nil {
+			return err
+		}
+		_, err := out.Write(page[:])
+		return err
+	})
+}
+
+func TestUnmarshalJSONRejectsTruncatedBlocks(t *testing.T) {
+	entries := []pageEntry{
+		{
+			Index:  1,
+			Bitmap: []byte{0b11}, // marks 2 blocks non-zero
+			Blocks: [][]byte{make([]byte, pageBitmapBlockSize)}, // only 1 provided
+		},
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got := NewMemory()
+	if err := json.Unmarshal(data, got); err == nil {
+		t.Fatal("expected an error decoding a page with fewer blocks than the bitmap marks, got nil")
+	}
+}
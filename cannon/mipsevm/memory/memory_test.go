@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"testing"
+)
+
+// TestMerkleProofAcrossBuckets guards against a dispatcher bug where
+// MerkleizeSubtree routed every gindex with l <= PageKeySize into
+// merkleizeTop, overflowing topLocal (sized for l <= topBits only) for any
+// gindex whose bit-length actually falls at the bucket level. traverseBranch
+// calls MerkleizeSubtree at every depth on the root-to-leaf path, so a plain
+// MerkleProof on ordinary memory used to panic.
+func TestMerkleProofAcrossBuckets(t *testing.T) {
+	m := NewMemory()
+	// touch pages in a few different buckets so the proof path crosses the
+	// bucket level at more than one topIdx.
+	for i := 0; i < 8; i++ {
+		m.SetWord(Word(i)<<(PageAddrSize+bottomBits), Word(i+1))
+	}
+	for i := 0; i < 8; i++ {
+		addr := Word(i) << (PageAddrSize + bottomBits)
+		_ = m.MerkleProof(addr)
+	}
+}
+
+// TestMerkleRootMatchesAfterScatteredWrites is a basic sanity check that the
+// radix index produces a stable, order-independent root for writes spread
+// across many buckets and pages.
+func TestMerkleRootMatchesAfterScatteredWrites(t *testing.T) {
+	a := NewMemory()
+	b := NewMemory()
+
+	writes := []struct {
+		addr Word
+		val  Word
+	}{
+		{0x1000, 1},
+		{0x1000_0000, 2},
+		{0x7fff_f000, 3},
+		{0x2000, 4},
+	}
+
+	for _, w := range writes {
+		a.SetWord(w.addr, w.val)
+	}
+	for i := len(writes) - 1; i >= 0; i-- {
+		b.SetWord(writes[i].addr, writes[i].val)
+	}
+
+	if a.MerkleRoot() != b.MerkleRoot() {
+		t.Fatalf("expected order-independent root, got %x != %x", a.MerkleRoot(), b.MerkleRoot())
+	}
+}